@@ -0,0 +1,149 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// DevCommand bootstraps a throwaway Vault target: it generates dev-mode
+// TLS material, initializes and unseals the Vault instance at -address,
+// and stashes the resulting root token/unseal keys so the rest of
+// vaultops can pick it up without further setup.
+type DevCommand struct {
+	Meta
+
+	flagDevTLS        bool
+	flagDevTLSCertDir string
+	flagKeyShares     int
+	flagKeyThreshold  int
+}
+
+// Run satisfies the cli.Command interface
+func (c *DevCommand) Run(args []string) int {
+	f := c.Meta.FlagSet("dev", FlagSetServer)
+	f.BoolVar(&c.flagDevTLS, "dev-tls", false, "")
+	f.StringVar(&c.flagDevTLSCertDir, "dev-tls-cert-dir", "", "")
+	f.IntVar(&c.flagKeyShares, "key-shares", 5, "")
+	f.IntVar(&c.flagKeyThreshold, "key-threshold", 3, "")
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing args: %v", err))
+		return 1
+	}
+
+	address := strings.TrimSpace(f.Arg(0))
+
+	var certs *devTLSCerts
+	if c.flagDevTLS {
+		dir := c.flagDevTLSCertDir
+		if dir == "" {
+			dir = filepath.Join(localDir, "dev-tls")
+		}
+
+		var err error
+		certs, err = generateDevTLS(dir)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error generating dev TLS material: %v", err))
+			return 1
+		}
+
+		c.Meta.flagCACert = certs.CACert
+		c.Meta.flagClientCert = certs.ClientCert
+		c.Meta.flagClientKey = certs.ClientKey
+
+		c.UI.Output(fmt.Sprintf("==> Dev TLS: generated CA and client cert in %s. This command does not start a Vault listener: [address] must already be serving a certificate trusted by %s.", dir, certs.CACert))
+	}
+
+	// Sys().Init()/Sys().Unseal() are unauthenticated endpoints, and at
+	// this point nothing has issued a token yet, so build the client
+	// without going through token resolution.
+	client, err := c.Meta.UnauthenticatedClient(address)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error initializing Vault client: %v", err))
+		return 1
+	}
+
+	keys, err := c.initAndUnseal(client)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	store, err := c.Meta.keyStore()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if err := store.Write(filepath.Join(localDir, localFile), keys); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Vault dev server initialized and unsealed, keys written to %s", filepath.Join(localDir, localFile)))
+
+	return 0
+}
+
+// initAndUnseal runs Sys().Init against client using the configured
+// key-shares/key-threshold, then unseals the server with the resulting
+// master keys
+func (c *DevCommand) initAndUnseal(client *api.Client) (*VaultKeys, error) {
+	initResp, err := client.Sys().Init(&api.InitRequest{
+		SecretShares:    c.flagKeyShares,
+		SecretThreshold: c.flagKeyThreshold,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error initializing vault: %v", err)
+	}
+
+	for _, key := range initResp.Keys[:c.flagKeyThreshold] {
+		if _, err := client.Sys().Unseal(key); err != nil {
+			return nil, fmt.Errorf("Error unsealing vault: %v", err)
+		}
+	}
+
+	return &VaultKeys{
+		RootToken:  initResp.RootToken,
+		MasterKeys: initResp.Keys,
+	}, nil
+}
+
+// Help satisfies the cli.Command interface
+func (c *DevCommand) Help() string {
+	helpText := `
+Usage: vaultops dev [options] [address]
+
+  Bootstrap a dev-mode Vault target from scratch: initialize the Vault
+  instance at [address] (defaults to VAULT_ADDR), unseal it, and write
+  the resulting root token and unseal keys to .local/vault.json.
+
+General Options:
+` + GeneralOptionsUsage() + `
+Dev Options:
+
+  -dev-tls                Generate a throwaway CA and client certificate
+                           and use them for the connection to Vault. This
+                           does not start a Vault listener: [address]
+                           must already be serving a certificate trusted
+                           by the generated CA.
+
+  -dev-tls-cert-dir=path  Directory to write the generated TLS material
+                           to. Defaults to .local/dev-tls.
+
+  -key-shares=5           Number of key shares to split the Vault
+                           master key into.
+
+  -key-threshold=3        Number of key shares required to reconstruct
+                           the Vault master key.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis satisfies the cli.Command interface
+func (c *DevCommand) Synopsis() string {
+	return "Bootstrap a dev-mode Vault target with TLS, init and unseal"
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// devTLSCertDirMode is the permission mode used for the generated
+// dev-mode TLS certificate directory
+const devTLSCertDirMode = 0700
+
+// devTLSCerts holds the paths to the dev-mode TLS material generated by
+// generateDevTLS
+type devTLSCerts struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// generateDevTLS creates a throwaway CA and a client leaf certificate
+// under dir, for use by DevCommand to reach [address] over TLS. It does
+// NOT generate a server certificate: DevCommand never starts a Vault
+// listener itself, and it points -ca-cert at the generated CA, so the
+// target Vault's own server certificate must chain to that CA. The CA
+// private key is not written to disk, and a new CA is generated on
+// every call.
+func generateDevTLS(dir string) (*devTLSCerts, error) {
+	if err := os.MkdirAll(dir, devTLSCertDirMode); err != nil {
+		return nil, fmt.Errorf("Error creating %s: %v", dir, err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("Error generating CA key: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vaultops dev CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating CA certificate: %v", err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing CA certificate: %v", err)
+	}
+
+	certs := &devTLSCerts{
+		CACert:     filepath.Join(dir, "vault-ca.pem"),
+		ClientCert: filepath.Join(dir, "vault-client.pem"),
+		ClientKey:  filepath.Join(dir, "vault-client-key.pem"),
+	}
+
+	if err := writePEM(certs.CACert, "CERTIFICATE", caDER); err != nil {
+		return nil, err
+	}
+
+	if err := generateDevLeaf(certs.ClientCert, certs.ClientKey, caCert, caKey, "vaultops dev client", x509.ExtKeyUsageClientAuth); err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+// generateDevLeaf issues a single leaf certificate signed by caCert/caKey
+// and writes the certificate and private key to certPath/keyPath
+func generateDevLeaf(certPath, keyPath string, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, cn string, eku x509.ExtKeyUsage) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("Error generating key for %s: %v", cn, err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{eku},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("Error creating certificate for %s: %v", cn, err)
+	}
+
+	if err := writePEM(certPath, "CERTIFICATE", der); err != nil {
+		return err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("Error marshaling key for %s: %v", cn, err)
+	}
+
+	return writePEM(keyPath, "EC PRIVATE KEY", keyDER)
+}
+
+// writePEM PEM-encodes der under blockType and writes it to path
+func writePEM(path, blockType string, der []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", path, err)
+	}
+	defer f.Close()
+
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: der})
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+)
+
+// ageKeyStore encrypts the whole VaultKeys JSON blob to one or more age
+// recipients, rather than splitting it into per-operator shares
+type ageKeyStore struct {
+	// Recipients are age recipient strings (age1...)
+	Recipients []string
+	// IdentityFile is the operator's age identity file, used on Read
+	IdentityFile string
+}
+
+// Write satisfies the KeyStore interface
+func (s *ageKeyStore) Write(path string, keys *VaultKeys) error {
+	if len(s.Recipients) == 0 {
+		return fmt.Errorf("age key store requires at least one recipient")
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding vault keys: %v", err)
+	}
+
+	recipients := make([]age.Recipient, 0, len(s.Recipients))
+	for _, r := range s.Recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return fmt.Errorf("Error parsing age recipient %q: %v", r, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return fmt.Errorf("Error encrypting vault keys: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("Error encrypting vault keys: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Error encrypting vault keys: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error creating %s: %v", filepath.Dir(path), err)
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// Read satisfies the KeyStore interface
+func (s *ageKeyStore) Read(path string) (*VaultKeys, error) {
+	if s.IdentityFile == "" {
+		return nil, fmt.Errorf("age key store requires an identity file to decrypt")
+	}
+
+	identityData, err := ioutil.ReadFile(s.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading age identity %s: %v", s.IdentityFile, err)
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(identityData))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing age identity %s: %v", s.IdentityFile, err)
+	}
+
+	ciphertext, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", path, err)
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting %s: %v", path, err)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting %s: %v", path, err)
+	}
+
+	keys := &VaultKeys{}
+	if err := json.Unmarshal(data, keys); err != nil {
+		return nil, fmt.Errorf("Error decoding vault keys from %s: %v", path, err)
+	}
+
+	return keys, nil
+}
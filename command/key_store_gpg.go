@@ -0,0 +1,162 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// gpgShareKeyStore splits VaultKeys.MasterKeys across N operator
+// supplied GPG recipients, one share per file, so no single file
+// contains enough material to unseal Vault on its own. This mirrors the
+// way `vault operator init -pgp-keys` hands each unseal key to a
+// different operator.
+type gpgShareKeyStore struct {
+	// Recipients are paths to armored GPG public keys, one per share
+	Recipients []string
+	// RootTokenRecipient is the armored GPG public key the root token is
+	// encrypted to. If unset, the root token is encrypted to Recipients[0],
+	// meaning that operator gets standalone access to it as soon as Vault
+	// is unsealed. Set this to a recipient outside Recipients (mirroring
+	// `vault operator init -root-token-pgp-key`) to keep quorum required
+	// for the master key shares separate from root token access.
+	RootTokenRecipient string
+}
+
+// sharePath returns the per-recipient share file path derived from the
+// base VaultKeys path, e.g. .local/vault.json -> .local/vault.0.gpg
+func sharePath(path string, i int) string {
+	ext := filepath.Ext(path)
+	base := path[:len(path)-len(ext)]
+	return fmt.Sprintf("%s.%d.gpg", base, i)
+}
+
+// rootTokenPath returns the root token share file path
+func rootTokenPath(path string) string {
+	ext := filepath.Ext(path)
+	return path[:len(path)-len(ext)] + ".root-token.gpg"
+}
+
+// Write satisfies the KeyStore interface
+func (s *gpgShareKeyStore) Write(path string, keys *VaultKeys) error {
+	if len(s.Recipients) == 0 {
+		return fmt.Errorf("gpg key store requires at least one recipient")
+	}
+	if len(keys.MasterKeys) > len(s.Recipients) {
+		return fmt.Errorf("have %d master key shares but only %d recipients", len(keys.MasterKeys), len(s.Recipients))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error creating %s: %v", filepath.Dir(path), err)
+	}
+
+	for i, share := range keys.MasterKeys {
+		entity, err := loadPGPEntity(s.Recipients[i])
+		if err != nil {
+			return err
+		}
+
+		if err := encryptToFile(sharePath(path, i), []byte(share), entity); err != nil {
+			return err
+		}
+	}
+
+	if keys.RootToken != "" {
+		recipient := s.RootTokenRecipient
+		if recipient == "" {
+			recipient = s.Recipients[0]
+		}
+
+		entity, err := loadPGPEntity(recipient)
+		if err != nil {
+			return err
+		}
+
+		if err := encryptToFile(rootTokenPath(path), []byte(keys.RootToken), entity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read satisfies the KeyStore interface. It decrypts whichever shares
+// the operator's own gpg-agent holds a private key for, so a single
+// operator typically recovers only their own share.
+func (s *gpgShareKeyStore) Read(path string) (*VaultKeys, error) {
+	keys := &VaultKeys{}
+
+	for i := range s.Recipients {
+		data, err := gpgDecrypt(sharePath(path, i))
+		if err != nil {
+			continue
+		}
+		keys.MasterKeys = append(keys.MasterKeys, string(data))
+	}
+
+	if data, err := gpgDecrypt(rootTokenPath(path)); err == nil {
+		keys.RootToken = string(data)
+	}
+
+	if len(keys.MasterKeys) == 0 && keys.RootToken == "" {
+		return nil, fmt.Errorf("Error reading gpg key shares from %s: gpg could not decrypt any share with a key available to the local agent", filepath.Dir(path))
+	}
+
+	return keys, nil
+}
+
+// loadPGPEntity reads an armored public key from path
+func loadPGPEntity(path string) (*openpgp.Entity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading pgp recipient %s: %v", path, err)
+	}
+	defer f.Close()
+
+	keyring, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing pgp recipient %s: %v", path, err)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("Error parsing pgp recipient %s: no keys found", path)
+	}
+
+	return keyring[0], nil
+}
+
+// encryptToFile PGP-encrypts data to entity and writes the result to path
+func encryptToFile(path string, data []byte, entity *openpgp.Entity) error {
+	var buf bytes.Buffer
+	w, err := openpgp.Encrypt(&buf, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Error encrypting to %s: %v", path, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("Error encrypting to %s: %v", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Error encrypting to %s: %v", path, err)
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// gpgDecrypt decrypts the PGP message at path by shelling out to the
+// system `gpg` binary, which talks to gpg-agent for the private key
+// lookup and any pinentry passphrase prompt. Modern GnuPG (>= 2.1)
+// keeps private keys in private-keys-v1.d behind the agent rather than
+// in a flat secring.gpg, so `gpg --decrypt` is the only reliable way to
+// reach them from here.
+func gpgDecrypt(path string) ([]byte, error) {
+	out, err := exec.Command("gpg", "--batch", "--yes", "--decrypt", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting %s via gpg: %v", path, err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,212 @@
+package command
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	gkms "cloud.google.com/go/kms/apiv1"
+	kmspb "cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// kmsEnvelope is the on-disk representation for kmsKeyStore: the
+// VaultKeys blob encrypted with a random data key, plus that data key
+// encrypted ("wrapped") by the cloud KMS key
+type kmsEnvelope struct {
+	// EncryptedDataKey is the KMS-wrapped AES-256 data key
+	EncryptedDataKey []byte `json:"encrypted_data_key"`
+	// Nonce is the AES-GCM nonce used for Ciphertext
+	Nonce []byte `json:"nonce"`
+	// Ciphertext is the VaultKeys JSON blob encrypted under the data key
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// kmsKeyStore envelope-encrypts VaultKeys with a per-write AES-256 data
+// key, itself encrypted by a cloud KMS key so the plaintext data key
+// never touches disk
+type kmsKeyStore struct {
+	// Provider is "aws" or "gcp"
+	Provider string
+	// KeyID identifies the KMS key: a key ARN for aws, or a
+	// projects/.../cryptoKeys/... resource name for gcp
+	KeyID string
+}
+
+// Write satisfies the KeyStore interface
+func (s *kmsKeyStore) Write(path string, keys *VaultKeys) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("Error encoding vault keys: %v", err)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return fmt.Errorf("Error generating data key: %v", err)
+	}
+
+	ciphertext, nonce, err := aesGCMSeal(dataKey, data)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := s.wrapDataKey(dataKey)
+	if err != nil {
+		return err
+	}
+
+	envelope := &kmsEnvelope{EncryptedDataKey: wrapped, Nonce: nonce, Ciphertext: ciphertext}
+	out, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding kms envelope: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error creating %s: %v", filepath.Dir(path), err)
+	}
+
+	return ioutil.WriteFile(path, out, 0600)
+}
+
+// Read satisfies the KeyStore interface
+func (s *kmsKeyStore) Read(path string) (*VaultKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", path, err)
+	}
+
+	envelope := &kmsEnvelope{}
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, fmt.Errorf("Error decoding kms envelope from %s: %v", path, err)
+	}
+
+	dataKey, err := s.unwrapDataKey(envelope.EncryptedDataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aesGCMOpen(dataKey, envelope.Nonce, envelope.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting %s: %v", path, err)
+	}
+
+	keys := &VaultKeys{}
+	if err := json.Unmarshal(plaintext, keys); err != nil {
+		return nil, fmt.Errorf("Error decoding vault keys from %s: %v", path, err)
+	}
+
+	return keys, nil
+}
+
+// wrapDataKey encrypts dataKey using the configured cloud KMS key.
+// AWS and GCP credentials are resolved from the environment in each
+// SDK's usual way (AWS_* / GOOGLE_APPLICATION_CREDENTIALS).
+func (s *kmsKeyStore) wrapDataKey(dataKey []byte) ([]byte, error) {
+	switch s.Provider {
+	case "aws":
+		svc := kms.New(session.Must(session.NewSession()))
+		out, err := svc.Encrypt(&kms.EncryptInput{
+			KeyId:     aws.String(s.KeyID),
+			Plaintext: dataKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error wrapping data key with aws kms: %v", err)
+		}
+		return out.CiphertextBlob, nil
+	case "gcp":
+		ctx := context.Background()
+		client, err := gkms.NewKeyManagementClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating gcp kms client: %v", err)
+		}
+		defer client.Close()
+
+		out, err := client.Encrypt(ctx, &kmspb.EncryptRequest{
+			Name:      s.KeyID,
+			Plaintext: dataKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error wrapping data key with gcp kms: %v", err)
+		}
+		return out.Ciphertext, nil
+	default:
+		return nil, fmt.Errorf("Unknown kms provider: %s", s.Provider)
+	}
+}
+
+// unwrapDataKey decrypts a KMS-wrapped data key
+func (s *kmsKeyStore) unwrapDataKey(wrapped []byte) ([]byte, error) {
+	switch s.Provider {
+	case "aws":
+		svc := kms.New(session.Must(session.NewSession()))
+		out, err := svc.Decrypt(&kms.DecryptInput{
+			KeyId:          aws.String(s.KeyID),
+			CiphertextBlob: wrapped,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error unwrapping data key with aws kms: %v", err)
+		}
+		return out.Plaintext, nil
+	case "gcp":
+		ctx := context.Background()
+		client, err := gkms.NewKeyManagementClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating gcp kms client: %v", err)
+		}
+		defer client.Close()
+
+		out, err := client.Decrypt(ctx, &kmspb.DecryptRequest{
+			Name:       s.KeyID,
+			Ciphertext: wrapped,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error unwrapping data key with gcp kms: %v", err)
+		}
+		return out.Plaintext, nil
+	default:
+		return nil, fmt.Errorf("Unknown kms provider: %s", s.Provider)
+	}
+}
+
+// aesGCMSeal encrypts plaintext with key under a random nonce
+func aesGCMSeal(key, plaintext []byte) (ciphertext, nonce []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return gcm.Seal(nil, nonce, plaintext, nil), nonce, nil
+}
+
+// aesGCMOpen decrypts ciphertext with key and nonce
+func aesGCMOpen(key, nonce, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
@@ -3,7 +3,9 @@ package command
 import (
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/mitchellh/cli"
@@ -24,6 +26,8 @@ const (
 	EnvVaultTLSServerName = "VAULT_TLS_SERVER_NAME"
 	// EnvVaultToken stores vault token env var name
 	EnvVaultToken = "VAULT_TOKEN"
+	// EnvVaultNamespace stores vault namespace env var name
+	EnvVaultNamespace = "VAULT_NAMESPACE"
 	// localPath points to vault keys
 	localDir  = ".local"
 	localFile = "vault.json"
@@ -112,12 +116,28 @@ type Meta struct {
 	// UI is the cli UI
 	UI cli.Ui
 	// These are set by the command line flags.
-	flagAddress    string
-	flagCACert     string
-	flagCAPath     string
-	flagClientCert string
-	flagClientKey  string
-	flagInsecure   bool
+	flagAddress          string
+	flagCACert           string
+	flagCAPath           string
+	flagClientCert       string
+	flagClientKey        string
+	flagInsecure         bool
+	flagTLSServerName    string
+	flagNamespace        string
+	flagAuthMethod       string
+	flagAuthPath         string
+	flagAuthRole         string
+	flagAuthRoleID       string
+	flagAuthSecretID     string
+	flagAuthSecretIDFile string
+	flagAuthJWTFile      string
+	flagKeyStore         string
+	flagPGPKeys          string
+	flagRootTokenPGPKey  string
+	flagAgeRecipients    string
+	flagAgeIdentity      string
+	flagKMSProvider      string
+	flagKMSKeyID         string
 }
 
 // FlagSet returns a FlagSet with the common flags that every
@@ -135,6 +155,22 @@ func (m *Meta) FlagSet(name string, fs FlagSetFlags) *flag.FlagSet {
 		f.StringVar(&m.flagClientKey, "client-key", "", "")
 		f.BoolVar(&m.flagInsecure, "insecure", false, "")
 		f.BoolVar(&m.flagInsecure, "tls-skip-verify", false, "")
+		f.StringVar(&m.flagTLSServerName, "tls-server-name", "", "")
+		f.StringVar(&m.flagNamespace, "namespace", "", "")
+		f.StringVar(&m.flagAuthMethod, "auth-method", "", "")
+		f.StringVar(&m.flagAuthPath, "auth-path", "", "")
+		f.StringVar(&m.flagAuthRole, "auth-role", "", "")
+		f.StringVar(&m.flagAuthRoleID, "auth-role-id", "", "")
+		f.StringVar(&m.flagAuthSecretID, "auth-secret-id", "", "")
+		f.StringVar(&m.flagAuthSecretIDFile, "auth-secret-id-file", "", "")
+		f.StringVar(&m.flagAuthJWTFile, "auth-jwt-file", "", "")
+		f.StringVar(&m.flagKeyStore, "key-store", "plaintext", "")
+		f.StringVar(&m.flagPGPKeys, "pgp-keys", "", "")
+		f.StringVar(&m.flagRootTokenPGPKey, "root-token-pgp-key", "", "")
+		f.StringVar(&m.flagAgeRecipients, "age-recipients", "", "")
+		f.StringVar(&m.flagAgeIdentity, "age-identity", "", "")
+		f.StringVar(&m.flagKMSProvider, "kms-provider", "", "")
+		f.StringVar(&m.flagKMSKeyID, "kms-key-id", "", "")
 	}
 
 	return f
@@ -159,13 +195,13 @@ func (m *Meta) Config(address string) (*api.Config, error) {
 	}
 
 	// If we need custom TLS configuration, then set it
-	if m.flagCACert != "" || m.flagCAPath != "" || m.flagClientCert != "" || m.flagClientKey != "" || m.flagInsecure {
+	if m.flagCACert != "" || m.flagCAPath != "" || m.flagClientCert != "" || m.flagClientKey != "" || m.flagInsecure || m.flagTLSServerName != "" {
 		t := &api.TLSConfig{
 			CACert:        m.flagCACert,
 			CAPath:        m.flagCAPath,
 			ClientCert:    m.flagClientCert,
 			ClientKey:     m.flagClientKey,
-			TLSServerName: "",
+			TLSServerName: m.flagTLSServerName,
 			Insecure:      m.flagInsecure,
 		}
 		config.ConfigureTLS(t)
@@ -174,20 +210,73 @@ func (m *Meta) Config(address string) (*api.Config, error) {
 	return config, nil
 }
 
-// Client initializes vault api.Client and returns it or fails with error
-// or if mandatory options are missing. Ripped off (https://github.com/hashicorp/vault/blob/master/meta/meta.go#L74-L98)
-func (m *Meta) Client(address, token string) (*api.Client, error) {
+// Namespace returns the Vault Enterprise namespace to use, preferring an
+// explicit -namespace flag over the VAULT_NAMESPACE environment variable
+func (m *Meta) Namespace() string {
+	if m.flagNamespace != "" {
+		return m.flagNamespace
+	}
+
+	return os.Getenv(EnvVaultNamespace)
+}
+
+// keyStore builds the KeyStore selected via -key-store, defaulting to
+// plaintext JSON on disk
+func (m *Meta) keyStore() (KeyStore, error) {
+	switch m.flagKeyStore {
+	case "", "plaintext":
+		return plaintextKeyStore{}, nil
+	case "gpg-shares":
+		if m.flagPGPKeys == "" {
+			return nil, fmt.Errorf("-key-store=gpg-shares requires -pgp-keys")
+		}
+		return &gpgShareKeyStore{Recipients: strings.Split(m.flagPGPKeys, ","), RootTokenRecipient: m.flagRootTokenPGPKey}, nil
+	case "age":
+		if m.flagAgeRecipients == "" {
+			return nil, fmt.Errorf("-key-store=age requires -age-recipients")
+		}
+		return &ageKeyStore{Recipients: strings.Split(m.flagAgeRecipients, ","), IdentityFile: m.flagAgeIdentity}, nil
+	case "kms":
+		if m.flagKMSProvider == "" || m.flagKMSKeyID == "" {
+			return nil, fmt.Errorf("-key-store=kms requires -kms-provider and -kms-key-id")
+		}
+		return &kmsKeyStore{Provider: m.flagKMSProvider, KeyID: m.flagKMSKeyID}, nil
+	default:
+		return nil, fmt.Errorf("Unknown key store: %s", m.flagKeyStore)
+	}
+}
+
+// UnauthenticatedClient builds an api.Client for address without
+// resolving a token. Use this against a Vault that has no token yet,
+// e.g. to call the unauthenticated Sys().Init()/Sys().Unseal() endpoints
+// before any token exists to resolve.
+func (m *Meta) UnauthenticatedClient(address string) (*api.Client, error) {
 	config, err := m.Config(address)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build the client
 	client, err := api.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
 
+	// namespace is optional and only applies to Vault Enterprise
+	if ns := m.Namespace(); ns != "" {
+		client.SetNamespace(ns)
+	}
+
+	return client, nil
+}
+
+// Client initializes vault api.Client and returns it or fails with error
+// or if mandatory options are missing. Ripped off (https://github.com/hashicorp/vault/blob/master/meta/meta.go#L74-L98)
+func (m *Meta) Client(address, token string) (*api.Client, error) {
+	client, err := m.UnauthenticatedClient(address)
+	if err != nil {
+		return nil, err
+	}
+
 	// retrieve token
 	t := m.token
 
@@ -198,11 +287,28 @@ func (m *Meta) Client(address, token string) (*api.Client, error) {
 
 	// if still not found, lookup locally
 	if t == "" {
-		keys, err := readVaultKeys(filepath.Join(localDir, localFile))
+		store, err := m.keyStore()
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err := store.Read(filepath.Join(localDir, localFile))
+		if err != nil && m.flagAuthMethod == "" {
+			return nil, err
+		} else if err == nil {
+			t = keys.RootToken
+		}
+	}
+
+	// if still not found, try a configured auth method
+	if t == "" && m.flagAuthMethod != "" {
+		secret, err := m.authLogin(client)
 		if err != nil {
 			return nil, err
 		}
-		t = keys.RootToken
+		t = secret.Auth.ClientToken
+		m.token = t
+		go m.renewToken(client, secret)
 	}
 
 	// if we pass in token, override VAULT_TOKEN
@@ -210,11 +316,75 @@ func (m *Meta) Client(address, token string) (*api.Client, error) {
 		t = token
 		m.token = token
 	}
+
+	if t == "" {
+		return nil, fmt.Errorf("Error initializing Vault client: could not resolve a token from VAULT_TOKEN, -key-store or -auth-method")
+	}
+
 	client.SetToken(t)
 
 	return client, nil
 }
 
+// authLogin builds the TokenSource configured via -auth-method and logs
+// in against client, returning the resulting auth secret
+func (m *Meta) authLogin(client *api.Client) (*api.Secret, error) {
+	path := m.flagAuthPath
+	if path == "" {
+		path = m.flagAuthMethod
+	}
+
+	var source TokenSource
+	switch m.flagAuthMethod {
+	case "approle":
+		source = &AppRoleTokenSource{Path: path, RoleID: m.flagAuthRoleID, SecretID: m.flagAuthSecretID, SecretIDFile: m.flagAuthSecretIDFile}
+	case "kubernetes":
+		source = &KubernetesTokenSource{Path: path, Role: m.flagAuthRole, JWTFile: m.flagAuthJWTFile}
+	case "jwt", "oidc":
+		source = &JWTTokenSource{Path: path, Role: m.flagAuthRole, JWTFile: m.flagAuthJWTFile}
+	case "cert":
+		source = &ClientCertTokenSource{Path: path, Role: m.flagAuthRole}
+	default:
+		return nil, fmt.Errorf("Unknown auth method: %s", m.flagAuthMethod)
+	}
+
+	secret, err := source.Login(client)
+	if err != nil {
+		return nil, fmt.Errorf("Error logging in via %s: %v", m.flagAuthMethod, err)
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, fmt.Errorf("Error logging in via %s: no auth info returned", m.flagAuthMethod)
+	}
+
+	return secret, nil
+}
+
+// renewToken keeps secret's token alive for as long as the process runs,
+// renewing it shortly before its lease expires
+func (m *Meta) renewToken(client *api.Client, secret *api.Secret) {
+	watcher, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{Secret: secret})
+	if err != nil {
+		if m.UI != nil {
+			m.UI.Error(fmt.Sprintf("Error starting token renewer: %v", err))
+		}
+		return
+	}
+
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case err := <-watcher.DoneCh():
+			if err != nil && m.UI != nil {
+				m.UI.Error(fmt.Sprintf("Error renewing auth token: %v", err))
+			}
+			return
+		case <-watcher.RenewCh():
+		}
+	}
+}
+
 // Token returns client token
 func (m *Meta) Token() string {
 	return m.token
@@ -249,7 +419,70 @@ func GeneralOptionsUsage() string {
   -tls-skip-verify        Do not verify TLS certificate. This is highly
                           not recommended. Verification will also be skipped
                           if VAULT_SKIP_VERIFY is set.
+
+  -tls-server-name=name   Name to use as the SNI host when connecting via
+                          TLS. Overrides the VAULT_TLS_SERVER_NAME
+                          environment variable if set.
+
+  -namespace=namespace    The Vault Enterprise namespace to prepend to
+                          requests. Overrides the VAULT_NAMESPACE
+                          environment variable if set.
+
+  -auth-method=method     Authenticate with the given auth method instead
+                          of a static token. One of: approle, kubernetes,
+                          jwt, oidc, cert.
+
+  -auth-path=path         Mount path of the auth method. Defaults to
+                          -auth-method's name.
+
+  -auth-role=role         Role to authenticate as for kubernetes, jwt,
+                          oidc and cert auth methods.
+
+  -auth-role-id=id        AppRole role_id, used with -auth-method=approle.
+
+  -auth-secret-id=id      AppRole secret_id, used with -auth-method=approle.
+                          Prefer -auth-secret-id-file where possible, since
+                          this puts the secret_id on the command line.
+
+  -auth-secret-id-file=path  Path to a file containing the AppRole
+                          secret_id, used with -auth-method=approle.
+                          Takes precedence over -auth-secret-id.
+
+  -auth-jwt-file=path     Path to the JWT to present, used with
+                          -auth-method=kubernetes or -auth-method=jwt.
+                          Defaults to the Kubernetes projected service
+                          account token path for -auth-method=kubernetes.
+
+  -key-store=store        Backend used to read/write .local/vault.json.
+                          One of: plaintext, gpg-shares, age, kms.
+                          Defaults to plaintext.
+
+  -pgp-keys=paths         Comma separated armored GPG public key files,
+                          one per master key share, used with
+                          -key-store=gpg-shares. Unless -root-token-pgp-key
+                          is also set, the root token is encrypted to the
+                          first key in this list, giving that operator
+                          standalone root access once Vault is unsealed.
+
+  -root-token-pgp-key=path  Armored GPG public key the root token is
+                          encrypted to, used with -key-store=gpg-shares.
+                          Set this to a recipient outside -pgp-keys to
+                          keep root token access separate from the
+                          master key share quorum.
+
+  -age-recipients=keys    Comma separated age recipients (age1...) used
+                          with -key-store=age.
+
+  -age-identity=path      Path to the operator's age identity file, used
+                          to decrypt with -key-store=age.
+
+  -kms-provider=provider  Cloud KMS provider used with -key-store=kms.
+                          One of: aws, gcp.
+
+  -kms-key-id=id          Cloud KMS key identifier used with
+                          -key-store=kms: a key ARN for aws, or a
+                          cryptoKeys resource name for gcp.
 `
 
 	return general
-}
\ No newline at end of file
+}
@@ -0,0 +1,306 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// PKICommand configures Vault's PKI secrets engine: standing up an
+// intermediate CA signed by a parent mount (or cross-signed by a
+// second one), and configuring the AIA URLs a mount advertises.
+type PKICommand struct {
+	Meta
+
+	flagBackend               string
+	flagParentBackend         string
+	flagParentRole            string
+	flagCSRType               string
+	flagCommonName            string
+	flagTTL                   string
+	flagCrossSign             bool
+	flagCrossSignBackend      string
+	flagCrossSignRole         string
+	flagCrossSignOutput       string
+	flagPrivateKeyOutput      string
+	flagIssuingCertificates   string
+	flagCRLDistributionPoints string
+}
+
+// Run satisfies the cli.Command interface
+func (c *PKICommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.UI.Error("Error: missing subcommand, expected one of: intermediate, urls")
+		return 1
+	}
+
+	sub := args[0]
+	args = args[1:]
+
+	f := c.Meta.FlagSet("pki", FlagSetServer)
+	f.StringVar(&c.flagBackend, "backend", "", "")
+	f.StringVar(&c.flagParentBackend, "parent-backend", "", "")
+	f.StringVar(&c.flagParentRole, "parent-role", "", "")
+	f.StringVar(&c.flagCSRType, "csr-type", "internal", "")
+	f.StringVar(&c.flagCommonName, "common-name", "", "")
+	f.StringVar(&c.flagTTL, "ttl", "", "")
+	f.BoolVar(&c.flagCrossSign, "cross-sign", false, "")
+	f.StringVar(&c.flagCrossSignBackend, "cross-sign-backend", "", "")
+	f.StringVar(&c.flagCrossSignRole, "cross-sign-role", "", "")
+	f.StringVar(&c.flagCrossSignOutput, "cross-sign-output", "", "")
+	f.StringVar(&c.flagPrivateKeyOutput, "private-key-output", "", "")
+	f.StringVar(&c.flagIssuingCertificates, "issuing-certificates", "", "")
+	f.StringVar(&c.flagCRLDistributionPoints, "crl-distribution-points", "", "")
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing args: %v", err))
+		return 1
+	}
+
+	client, err := c.Meta.Client("", "")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error initializing Vault client: %v", err))
+		return 1
+	}
+
+	switch sub {
+	case "intermediate":
+		err = c.intermediate(client)
+	case "urls":
+		err = c.urls(client)
+	default:
+		err = fmt.Errorf("Unknown subcommand: %s, expected one of: intermediate, urls", sub)
+	}
+
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// intermediate generates an intermediate CSR at c.flagBackend, has it
+// signed at c.flagParentBackend, and posts the signed certificate back
+// to c.flagBackend. If -cross-sign is set, it additionally signs the
+// same CSR at -cross-sign-backend and writes that chain to
+// -cross-sign-output, so leaf certs stay valid through a CA rollover.
+// With -csr-type=exported, Backend does not keep the intermediate's
+// private key, so it must be captured from the generate response and
+// written to -private-key-output or it is lost forever.
+func (c *PKICommand) intermediate(client *api.Client) error {
+	if c.flagBackend == "" || c.flagParentBackend == "" || c.flagCommonName == "" {
+		return fmt.Errorf("-backend, -parent-backend and -common-name are required")
+	}
+	if c.flagCSRType == "exported" && c.flagPrivateKeyOutput == "" {
+		return fmt.Errorf("-private-key-output is required when -csr-type=exported, or the intermediate private key is lost")
+	}
+
+	csrSecret, err := client.Logical().Write(fmt.Sprintf("%s/intermediate/generate/%s", c.flagBackend, c.flagCSRType), map[string]interface{}{
+		"common_name": c.flagCommonName,
+	})
+	if err != nil {
+		return fmt.Errorf("Error generating intermediate csr at %s: %v", c.flagBackend, err)
+	}
+
+	csr, ok := csrSecret.Data["csr"].(string)
+	if !ok {
+		return fmt.Errorf("Error generating intermediate csr at %s: no csr returned", c.flagBackend)
+	}
+
+	if c.flagCSRType == "exported" {
+		privateKey, ok := csrSecret.Data["private_key"].(string)
+		if !ok {
+			return fmt.Errorf("Error generating intermediate csr at %s: -csr-type=exported but no private_key returned", c.flagBackend)
+		}
+		if err := ioutil.WriteFile(c.flagPrivateKeyOutput, []byte(privateKey), 0600); err != nil {
+			return fmt.Errorf("Error writing intermediate private key to %s: %v", c.flagPrivateKeyOutput, err)
+		}
+		c.UI.Output(fmt.Sprintf("==> Intermediate private key for %s written to %s", c.flagBackend, c.flagPrivateKeyOutput))
+	}
+
+	if err := c.signAndSet(client, csr, c.flagParentBackend, c.flagParentRole, c.flagBackend); err != nil {
+		return err
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Signed intermediate CA at %s with parent %s", c.flagBackend, c.flagParentBackend))
+
+	if c.flagCrossSign {
+		if c.flagCrossSignBackend == "" {
+			return fmt.Errorf("-cross-sign-backend is required when -cross-sign is set")
+		}
+
+		signSecret, err := c.sign(client, csr, c.flagCrossSignBackend, c.flagCrossSignRole)
+		if err != nil {
+			return err
+		}
+
+		chain := crossSignChain(signSecret)
+		if c.flagCrossSignOutput != "" {
+			if err := ioutil.WriteFile(c.flagCrossSignOutput, []byte(chain), 0644); err != nil {
+				return fmt.Errorf("Error writing cross-signed chain to %s: %v", c.flagCrossSignOutput, err)
+			}
+			c.UI.Output(fmt.Sprintf("==> Cross-signed chain from %s written to %s", c.flagCrossSignBackend, c.flagCrossSignOutput))
+		} else {
+			c.UI.Output(chain)
+		}
+	}
+
+	return nil
+}
+
+// sign submits csr to parentBackend's root sign-intermediate endpoint,
+// or to its sign/<parentRole> endpoint when parentRole is set
+func (c *PKICommand) sign(client *api.Client, csr, parentBackend, parentRole string) (*api.Secret, error) {
+	data := map[string]interface{}{
+		"csr":         csr,
+		"common_name": c.flagCommonName,
+	}
+	if c.flagTTL != "" {
+		data["ttl"] = c.flagTTL
+	}
+
+	path := fmt.Sprintf("%s/root/sign-intermediate", parentBackend)
+	if parentRole != "" {
+		path = fmt.Sprintf("%s/sign/%s", parentBackend, parentRole)
+	}
+
+	secret, err := client.Logical().Write(path, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error signing intermediate csr at %s: %v", parentBackend, err)
+	}
+
+	return secret, nil
+}
+
+// signAndSet signs csr at parentBackend and posts the resulting
+// certificate (plus CA chain) back to childBackend/intermediate/set-signed
+func (c *PKICommand) signAndSet(client *api.Client, csr, parentBackend, parentRole, childBackend string) error {
+	secret, err := c.sign(client, csr, parentBackend, parentRole)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Logical().Write(fmt.Sprintf("%s/intermediate/set-signed", childBackend), map[string]interface{}{
+		"certificate": crossSignChain(secret),
+	})
+	if err != nil {
+		return fmt.Errorf("Error setting signed intermediate cert at %s: %v", childBackend, err)
+	}
+
+	return nil
+}
+
+// crossSignChain concatenates the signed certificate and its CA chain
+// from a sign-intermediate response into the PEM bundle Vault expects
+func crossSignChain(secret *api.Secret) string {
+	parts := []string{}
+
+	if cert, ok := secret.Data["certificate"].(string); ok {
+		parts = append(parts, cert)
+	}
+
+	if chain, ok := secret.Data["ca_chain"].([]interface{}); ok {
+		for _, c := range chain {
+			if s, ok := c.(string); ok {
+				parts = append(parts, s)
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// urls configures the issuing_certificates and crl_distribution_points
+// URLs c.flagBackend advertises in issued certificates
+func (c *PKICommand) urls(client *api.Client) error {
+	if c.flagBackend == "" {
+		return fmt.Errorf("-backend is required")
+	}
+
+	data := map[string]interface{}{}
+	if c.flagIssuingCertificates != "" {
+		data["issuing_certificates"] = strings.Split(c.flagIssuingCertificates, ",")
+	}
+	if c.flagCRLDistributionPoints != "" {
+		data["crl_distribution_points"] = strings.Split(c.flagCRLDistributionPoints, ",")
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("-issuing-certificates or -crl-distribution-points is required")
+	}
+
+	_, err := client.Logical().Write(fmt.Sprintf("%s/config/urls", c.flagBackend), data)
+	if err != nil {
+		return fmt.Errorf("Error configuring urls at %s: %v", c.flagBackend, err)
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Configured urls at %s/config/urls", c.flagBackend))
+
+	return nil
+}
+
+// Help satisfies the cli.Command interface
+func (c *PKICommand) Help() string {
+	helpText := `
+Usage: vaultops pki <subcommand> [options]
+
+  Stand up a PKI intermediate CA signed by a parent mount, optionally
+  cross-signed by a second one, and configure the AIA URLs a mount
+  advertises in issued certificates.
+
+Subcommands:
+
+  intermediate    Generate an intermediate CSR at -backend, sign it at
+                  -parent-backend, and set the signed cert back on
+                  -backend. With -cross-sign, also sign it at
+                  -cross-sign-backend.
+  urls            Configure -issuing-certificates/-crl-distribution-points
+                  on -backend
+
+General Options:
+` + GeneralOptionsUsage() + `
+PKI Options:
+
+  -backend=path               Child mount to generate/receive the
+                               intermediate CA at.
+
+  -parent-backend=path        Parent mount that signs the intermediate CSR.
+
+  -parent-role=name           Role at -parent-backend to sign with,
+                               instead of its root sign-intermediate
+                               endpoint.
+
+  -csr-type=internal          "internal" or "exported", controlling
+                               whether -backend keeps the intermediate
+                               private key. -csr-type=exported
+                               requires -private-key-output.
+
+  -private-key-output=path     Path to write the intermediate private key
+                               to. Required with -csr-type=exported.
+
+  -common-name=name           Common name for the intermediate CSR.
+
+  -ttl=duration                TTL of the signed intermediate cert.
+
+  -cross-sign                  Also sign the CSR at -cross-sign-backend.
+
+  -cross-sign-backend=path     Second parent mount to cross-sign with.
+
+  -cross-sign-role=name        Role at -cross-sign-backend to sign with.
+
+  -cross-sign-output=path      Path to write the cross-signed chain to.
+                               Defaults to stdout.
+
+  -issuing-certificates=urls   Comma separated issuing_certificates URLs.
+
+  -crl-distribution-points=urls  Comma separated crl_distribution_points URLs.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis satisfies the cli.Command interface
+func (c *PKICommand) Synopsis() string {
+	return "Stand up a PKI intermediate CA chain with optional cross-signing"
+}
@@ -0,0 +1,229 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// SSHCommand configures Vault's SSH secrets engine: generating or
+// importing a CA keypair at a mount, creating signer roles on top of
+// it, and optionally signing a user's public key.
+type SSHCommand struct {
+	Meta
+
+	flagBackend           string
+	flagGenerate          bool
+	flagPublicKey         string
+	flagPrivateKey        string
+	flagRole              string
+	flagDefaultUser       string
+	flagAllowedUsers      string
+	flagTTL               string
+	flagAllowedExtensions string
+	flagSignKey           string
+	flagOutput            string
+}
+
+// Run satisfies the cli.Command interface
+func (c *SSHCommand) Run(args []string) int {
+	if len(args) == 0 {
+		c.UI.Error("Error: missing subcommand, expected one of: ca, role, sign")
+		return 1
+	}
+
+	sub := args[0]
+	args = args[1:]
+
+	f := c.Meta.FlagSet("ssh", FlagSetServer)
+	f.StringVar(&c.flagBackend, "backend", "ssh", "")
+	f.BoolVar(&c.flagGenerate, "generate", true, "")
+	f.StringVar(&c.flagPublicKey, "public-key", "", "")
+	f.StringVar(&c.flagPrivateKey, "private-key", "", "")
+	f.StringVar(&c.flagRole, "role", "", "")
+	f.StringVar(&c.flagDefaultUser, "default-user", "", "")
+	f.StringVar(&c.flagAllowedUsers, "allowed-users", "", "")
+	f.StringVar(&c.flagTTL, "ttl", "", "")
+	f.StringVar(&c.flagAllowedExtensions, "allowed-extensions", "", "")
+	f.StringVar(&c.flagSignKey, "sign-key", "", "")
+	f.StringVar(&c.flagOutput, "output", "", "")
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(fmt.Sprintf("Error parsing args: %v", err))
+		return 1
+	}
+
+	client, err := c.Meta.Client("", "")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error initializing Vault client: %v", err))
+		return 1
+	}
+
+	switch sub {
+	case "ca":
+		err = c.configureCA(client)
+	case "role":
+		err = c.createRole(client)
+	case "sign":
+		err = c.sign(client)
+	default:
+		err = fmt.Errorf("Unknown subcommand: %s, expected one of: ca, role, sign", sub)
+	}
+
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+// configureCA generates or imports the SSH CA keypair at c.flagBackend
+func (c *SSHCommand) configureCA(client *api.Client) error {
+	data := map[string]interface{}{
+		"generate_signing_key": c.flagGenerate,
+	}
+
+	if !c.flagGenerate {
+		if c.flagPublicKey == "" || c.flagPrivateKey == "" {
+			return fmt.Errorf("-public-key and -private-key are required when -generate=false")
+		}
+		data["public_key"] = c.flagPublicKey
+		data["private_key"] = c.flagPrivateKey
+	}
+
+	_, err := client.Logical().Write(fmt.Sprintf("%s/config/ca", c.flagBackend), data)
+	if err != nil {
+		return fmt.Errorf("Error configuring ssh ca at %s: %v", c.flagBackend, err)
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Configured ssh CA at %s/config/ca", c.flagBackend))
+
+	return nil
+}
+
+// createRole creates a signer role at c.flagBackend/roles/c.flagRole
+func (c *SSHCommand) createRole(client *api.Client) error {
+	if c.flagRole == "" {
+		return fmt.Errorf("-role is required")
+	}
+
+	data := map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+	}
+
+	if c.flagDefaultUser != "" {
+		data["default_user"] = c.flagDefaultUser
+	}
+	if c.flagAllowedUsers != "" {
+		data["allowed_users"] = c.flagAllowedUsers
+	}
+	if c.flagTTL != "" {
+		data["ttl"] = c.flagTTL
+	}
+	if c.flagAllowedExtensions != "" {
+		data["allowed_extensions"] = c.flagAllowedExtensions
+	}
+
+	_, err := client.Logical().Write(fmt.Sprintf("%s/roles/%s", c.flagBackend, c.flagRole), data)
+	if err != nil {
+		return fmt.Errorf("Error creating ssh role %s at %s: %v", c.flagRole, c.flagBackend, err)
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Created ssh role %s/roles/%s", c.flagBackend, c.flagRole))
+
+	return nil
+}
+
+// sign signs the public key at c.flagSignKey with c.flagRole and writes
+// the resulting certificate to c.flagOutput, or stdout if unset
+func (c *SSHCommand) sign(client *api.Client) error {
+	if c.flagRole == "" || c.flagSignKey == "" {
+		return fmt.Errorf("-role and -sign-key are required")
+	}
+
+	publicKey, err := ioutil.ReadFile(c.flagSignKey)
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %v", c.flagSignKey, err)
+	}
+
+	data := map[string]interface{}{
+		"public_key": strings.TrimSpace(string(publicKey)),
+	}
+
+	secret, err := client.Logical().Write(fmt.Sprintf("%s/sign/%s", c.flagBackend, c.flagRole), data)
+	if err != nil {
+		return fmt.Errorf("Error signing %s with role %s: %v", c.flagSignKey, c.flagRole, err)
+	}
+
+	cert, ok := secret.Data["signed_key"].(string)
+	if !ok {
+		return fmt.Errorf("Error signing %s: no signed_key returned", c.flagSignKey)
+	}
+
+	if c.flagOutput == "" {
+		c.UI.Output(cert)
+		return nil
+	}
+
+	if err := ioutil.WriteFile(c.flagOutput, []byte(cert), 0644); err != nil {
+		return fmt.Errorf("Error writing signed certificate to %s: %v", c.flagOutput, err)
+	}
+
+	c.UI.Output(fmt.Sprintf("==> Signed certificate written to %s", c.flagOutput))
+
+	return nil
+}
+
+// Help satisfies the cli.Command interface
+func (c *SSHCommand) Help() string {
+	helpText := `
+Usage: vaultops ssh <subcommand> [options]
+
+  Configure Vault's SSH secrets engine: generate or import a CA key,
+  create signer roles, and sign user public keys.
+
+Subcommands:
+
+  ca      Generate or import the SSH CA keypair at -backend
+  role    Create a signer role at -backend
+  sign    Sign -sign-key with -role and write the cert to -output
+
+General Options:
+` + GeneralOptionsUsage() + `
+SSH Options:
+
+  -backend=ssh             SSH secrets engine mount path.
+
+  -generate=true            Generate a new CA keypair. Set to false along
+                            with -public-key/-private-key to import one.
+
+  -public-key=key           CA public key to import, used with ca -generate=false.
+
+  -private-key=key          CA private key to import, used with ca -generate=false.
+
+  -role=name                Signer role name.
+
+  -default-user=user        Default login user for certs signed by this role.
+
+  -allowed-users=users      Comma separated list of allowed principals.
+
+  -ttl=duration             Certificate time-to-live, e.g. "1h".
+
+  -allowed-extensions=exts  Comma separated list of allowed cert extensions.
+
+  -sign-key=path            Path to the public key to sign.
+
+  -output=path              Path to write the signed certificate to.
+                            Defaults to stdout.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Synopsis satisfies the cli.Command interface
+func (c *SSHCommand) Synopsis() string {
+	return "Configure Vault's SSH secrets engine CA, roles and signing"
+}
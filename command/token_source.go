@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// defaultKubernetesJWTPath is where Kubernetes projects the service
+// account token into every pod
+const defaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// TokenSource knows how to obtain a Vault token by authenticating
+// against a configured Vault auth method. Implementations wrap the
+// `/v1/auth/<mount>/login` endpoints vault itself exposes.
+type TokenSource interface {
+	// Login authenticates against client and returns the resulting
+	// auth secret, which carries the client token and its lease.
+	Login(client *api.Client) (*api.Secret, error)
+}
+
+// AppRoleTokenSource authenticates using the AppRole auth method
+type AppRoleTokenSource struct {
+	// Path is the auth mount path, e.g. "approle"
+	Path string
+	// RoleID is the AppRole role_id
+	RoleID string
+	// SecretID is the AppRole secret_id. Prefer SecretIDFile so the
+	// secret_id doesn't end up on the command line or in shell history.
+	SecretID string
+	// SecretIDFile is the path to a file containing the AppRole
+	// secret_id, taking precedence over SecretID if both are set
+	SecretIDFile string
+}
+
+// Login satisfies the TokenSource interface
+func (s *AppRoleTokenSource) Login(client *api.Client) (*api.Secret, error) {
+	secretID := s.SecretID
+	if s.SecretIDFile != "" {
+		data, err := ioutil.ReadFile(s.SecretIDFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading approle secret_id from %s: %v", s.SecretIDFile, err)
+		}
+		secretID = strings.TrimSpace(string(data))
+	}
+
+	return client.Logical().Write(fmt.Sprintf("auth/%s/login", s.Path), map[string]interface{}{
+		"role_id":   s.RoleID,
+		"secret_id": secretID,
+	})
+}
+
+// KubernetesTokenSource authenticates using the Kubernetes auth method,
+// presenting the pod's projected service account token as the JWT
+type KubernetesTokenSource struct {
+	// Path is the auth mount path, e.g. "kubernetes"
+	Path string
+	// Role is the Kubernetes auth role to authenticate as
+	Role string
+	// JWTFile is the path to the service account token, defaults to
+	// defaultKubernetesJWTPath
+	JWTFile string
+}
+
+// Login satisfies the TokenSource interface
+func (s *KubernetesTokenSource) Login(client *api.Client) (*api.Secret, error) {
+	jwtFile := s.JWTFile
+	if jwtFile == "" {
+		jwtFile = defaultKubernetesJWTPath
+	}
+
+	jwt, err := ioutil.ReadFile(jwtFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading kubernetes service account token from %s: %v", jwtFile, err)
+	}
+
+	return client.Logical().Write(fmt.Sprintf("auth/%s/login", s.Path), map[string]interface{}{
+		"role": s.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+}
+
+// JWTTokenSource authenticates using the JWT/OIDC auth method with a
+// role-bound JWT read from a file
+type JWTTokenSource struct {
+	// Path is the auth mount path, e.g. "jwt"
+	Path string
+	// Role is the JWT auth role to authenticate as
+	Role string
+	// JWTFile is the path to the JWT to present
+	JWTFile string
+}
+
+// Login satisfies the TokenSource interface
+func (s *JWTTokenSource) Login(client *api.Client) (*api.Secret, error) {
+	jwt, err := ioutil.ReadFile(s.JWTFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading jwt from %s: %v", s.JWTFile, err)
+	}
+
+	return client.Logical().Write(fmt.Sprintf("auth/%s/login", s.Path), map[string]interface{}{
+		"role": s.Role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+}
+
+// ClientCertTokenSource authenticates using the TLS cert auth method,
+// relying on the client certificate already configured on the api.Client
+type ClientCertTokenSource struct {
+	// Path is the auth mount path, e.g. "cert"
+	Path string
+	// Role is the cert auth role to authenticate as, optional
+	Role string
+}
+
+// Login satisfies the TokenSource interface
+func (s *ClientCertTokenSource) Login(client *api.Client) (*api.Secret, error) {
+	data := map[string]interface{}{}
+	if s.Role != "" {
+		data["name"] = s.Role
+	}
+
+	return client.Logical().Write(fmt.Sprintf("auth/%s/login", s.Path), data)
+}
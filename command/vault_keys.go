@@ -0,0 +1,68 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// KeyStore persists and retrieves VaultKeys, optionally encrypting them
+// at rest. The default plaintextKeyStore simply reads/writes JSON, the
+// same way readVaultKeys/writeVaultKeys always have; other backends
+// layer GPG, age or cloud KMS encryption on top.
+type KeyStore interface {
+	// Read loads and decrypts the VaultKeys stored at path
+	Read(path string) (*VaultKeys, error)
+	// Write encrypts and persists keys to path
+	Write(path string, keys *VaultKeys) error
+}
+
+// plaintextKeyStore is the default KeyStore: plain JSON on disk, kept
+// for local dev use and backwards compatibility
+type plaintextKeyStore struct{}
+
+// Read satisfies the KeyStore interface
+func (plaintextKeyStore) Read(path string) (*VaultKeys, error) {
+	return readVaultKeys(path)
+}
+
+// Write satisfies the KeyStore interface
+func (plaintextKeyStore) Write(path string, keys *VaultKeys) error {
+	return writeVaultKeys(path, keys)
+}
+
+// readVaultKeys reads and decodes VaultKeys stored at path
+func readVaultKeys(path string) (*VaultKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading vault keys from %s: %v", path, err)
+	}
+
+	keys := &VaultKeys{}
+	if err := json.Unmarshal(data, keys); err != nil {
+		return nil, fmt.Errorf("Error decoding vault keys from %s: %v", path, err)
+	}
+
+	return keys, nil
+}
+
+// writeVaultKeys encodes and writes VaultKeys to path, creating any
+// missing parent directories along the way
+func writeVaultKeys(path string, keys *VaultKeys) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error creating %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding vault keys: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("Error writing vault keys to %s: %v", path, err)
+	}
+
+	return nil
+}